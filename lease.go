@@ -0,0 +1,209 @@
+package timers
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Lease represents a deadline that can be refreshed without allocating a new
+// timer on every refresh, inspired by the acquire/refresh/unlock pattern used
+// by distributed locks. Each Refresh moves the lease to whichever Timeline
+// bucket the new deadline rounds up to, sharing it with every other caller
+// expiring in the same window, just like Timeline.Context already does for
+// one-shot deadlines.
+//
+// A bucket a Lease has moved away from is never canceled eagerly: since
+// Timeline buckets are shared with every other caller that happens to expire
+// in the same resolution window, and those callers have no way to signal
+// that they're done with a bucket, there is no safe way to tell whether
+// canceling it early would also cancel an unrelated caller. The vacated
+// bucket is instead left to expire on its own, via its deadline or the
+// timeline's periodic cleanup.
+type Lease struct {
+	timeline *Timeline
+	ttl      time.Duration
+
+	mutex    sync.Mutex
+	gen      uint64
+	released bool
+
+	ctx *leaseContext
+}
+
+// Lease acquires a new Lease that initially expires after initialTTL has
+// passed, plus up to the timeline's resolution. If refreshInterval is
+// greater than zero, the lease refreshes itself automatically at that
+// cadence until Release is called; otherwise the caller is responsible for
+// calling Refresh.
+func (t *Timeline) Lease(initialTTL, refreshInterval time.Duration) *Lease {
+	now := time.Now()
+	d := t.bucket(now.Add(initialTTL), now)
+
+	l := &Lease{
+		timeline: t,
+		ttl:      initialTTL,
+		ctx:      newLeaseContext(d.context),
+	}
+
+	l.mutex.Lock()
+	l.attachLocked(d)
+	l.mutex.Unlock()
+
+	if refreshInterval > 0 {
+		l.scheduleAutoRefresh(refreshInterval)
+	}
+	return l
+}
+
+// Context returns a context.Context whose Deadline reflects the lease's
+// current bucket. It is done once the lease expires without being refreshed
+// in time, or once Release is called.
+func (l *Lease) Context() context.Context {
+	return l.ctx
+}
+
+// Refresh extends the lease by its initial TTL, measured from time.Now(),
+// moving it to the bucket for the new deadline. It returns false if the
+// lease has already expired or been released.
+//
+// The read of the current bucket and the attach to the new one happen while
+// l.mutex is held, so that concurrent calls to Refresh can't race each other
+// onto stale state.
+func (l *Lease) Refresh() bool {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	// The lease context is only marked done once the bucket's callback has
+	// run, so also check the current bucket itself in case it already expired.
+	l.ctx.mutex.Lock()
+	bucket := l.ctx.ctx
+	l.ctx.mutex.Unlock()
+
+	if l.released || l.ctx.isDone() || bucket.Err() != nil {
+		return false
+	}
+
+	now := time.Now()
+	l.attachLocked(l.timeline.bucket(now.Add(l.ttl), now))
+	return true
+}
+
+// Release ends the lease immediately: its context is canceled right away,
+// though the bucket it was holding is left to expire on its own.
+func (l *Lease) Release() {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	if l.released {
+		return
+	}
+	l.released = true
+
+	l.ctx.finish(context.Canceled)
+}
+
+// attachLocked moves the lease onto bucket d: the lease's context is rewired
+// to proxy it, and a callback is registered so that, if d's deadline is
+// reached while d is still the lease's current bucket, the lease's context
+// is marked done. l.mutex must already be held by the caller.
+func (l *Lease) attachLocked(d deadline) {
+	l.gen++
+	gen := l.gen
+
+	l.ctx.rewire(d.context)
+
+	d.onDone(func() {
+		l.mutex.Lock()
+		current := l.gen == gen
+		l.mutex.Unlock()
+
+		if current {
+			l.ctx.finish(d.context.Err())
+		}
+	})
+}
+
+// scheduleAutoRefresh chains Timeline.AfterFunc calls, each refreshing the
+// lease and then scheduling the next refresh, until the lease is released or
+// fails to refresh (because it already expired).
+func (l *Lease) scheduleAutoRefresh(interval time.Duration) {
+	var tick func()
+	tick = func() {
+		if l.Refresh() {
+			l.timeline.AfterFunc(interval, tick)
+		}
+	}
+	l.timeline.AfterFunc(interval, tick)
+}
+
+// leaseContext implements context.Context on behalf of a Lease, proxying to
+// whichever bucket context is currently active so that a refresh is
+// invisible to callers already holding the context.
+type leaseContext struct {
+	mutex sync.Mutex
+	ctx   context.Context
+	done  chan struct{}
+	err   error
+}
+
+func newLeaseContext(initial context.Context) *leaseContext {
+	return &leaseContext{ctx: initial, done: make(chan struct{})}
+}
+
+func (c *leaseContext) rewire(ctx context.Context) {
+	c.mutex.Lock()
+	c.ctx = ctx
+	c.mutex.Unlock()
+}
+
+// finish marks the lease context permanently done, the first time it is
+// called; later calls are no-ops.
+func (c *leaseContext) finish(err error) {
+	c.mutex.Lock()
+	select {
+	case <-c.done:
+	default:
+		c.err = err
+		close(c.done)
+	}
+	c.mutex.Unlock()
+}
+
+func (c *leaseContext) isDone() bool {
+	select {
+	case <-c.done:
+		return true
+	default:
+		return false
+	}
+}
+
+func (c *leaseContext) Deadline() (time.Time, bool) {
+	c.mutex.Lock()
+	ctx := c.ctx
+	c.mutex.Unlock()
+	return ctx.Deadline()
+}
+
+func (c *leaseContext) Done() <-chan struct{} {
+	return c.done
+}
+
+func (c *leaseContext) Err() error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	select {
+	case <-c.done:
+		return c.err
+	default:
+		return nil
+	}
+}
+
+func (c *leaseContext) Value(key interface{}) interface{} {
+	c.mutex.Lock()
+	ctx := c.ctx
+	c.mutex.Unlock()
+	return ctx.Value(key)
+}
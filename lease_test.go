@@ -0,0 +1,141 @@
+package timers
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestLease(t *testing.T) {
+	t.Run("expires if never refreshed", testLeaseExpires)
+	t.Run("refresh extends the deadline", testLeaseRefresh)
+	t.Run("release cancels the context immediately", testLeaseRelease)
+	t.Run("concurrent refreshes don't race", testLeaseConcurrentRefresh)
+	t.Run("an unrelated context sharing a vacated bucket is not canceled early", testLeaseUnrelatedContextSurvivesBucketMove)
+}
+
+func testLeaseExpires(t *testing.T) {
+	t.Parallel()
+
+	timeline := Timeline{Resolution: 1 * time.Millisecond}
+	defer timeline.Cancel()
+
+	lease := timeline.Lease(10*time.Millisecond, 0)
+
+	select {
+	case <-lease.Context().Done():
+	case <-time.After(time.Second):
+		t.Fatal("lease context was not done in time")
+	}
+
+	if err := lease.Context().Err(); err != context.DeadlineExceeded {
+		t.Error("bad lease context error:", err)
+	}
+
+	if lease.Refresh() {
+		t.Error("Refresh returned true for an already expired lease")
+	}
+}
+
+func testLeaseRefresh(t *testing.T) {
+	t.Parallel()
+
+	timeline := Timeline{Resolution: 1 * time.Millisecond}
+	defer timeline.Cancel()
+
+	lease := timeline.Lease(10*time.Millisecond, 3*time.Millisecond)
+	defer lease.Release()
+
+	select {
+	case <-lease.Context().Done():
+		t.Error("auto-refreshed lease context was done too early")
+	case <-time.After(25 * time.Millisecond):
+	}
+}
+
+func testLeaseRelease(t *testing.T) {
+	t.Parallel()
+
+	timeline := Timeline{Resolution: 1 * time.Millisecond}
+	defer timeline.Cancel()
+
+	lease := timeline.Lease(time.Hour, 0)
+	lease.Release()
+
+	select {
+	case <-lease.Context().Done():
+	case <-time.After(time.Second):
+		t.Fatal("released lease context was not done")
+	}
+
+	if err := lease.Context().Err(); err != context.Canceled {
+		t.Error("bad lease context error:", err)
+	}
+}
+
+func testLeaseConcurrentRefresh(t *testing.T) {
+	t.Parallel()
+
+	timeline := Timeline{Resolution: 10 * time.Millisecond}
+	defer timeline.Cancel()
+
+	const ttl = 50 * time.Millisecond
+
+	lease := timeline.Lease(ttl, 0)
+	defer lease.Release()
+
+	// Run with -race: concurrent refreshes of the same lease must not race
+	// on its internal state.
+	var wg sync.WaitGroup
+	for i := 0; i != 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			lease.Refresh()
+		}()
+	}
+	wg.Wait()
+}
+
+func testLeaseUnrelatedContextSurvivesBucketMove(t *testing.T) {
+	t.Parallel()
+
+	timeline := Timeline{Resolution: 10 * time.Millisecond}
+	defer timeline.Cancel()
+
+	const ttl = 100 * time.Millisecond
+	now := time.Now()
+
+	lease := timeline.Lease(ttl, 0)
+	defer lease.Release()
+
+	// An unrelated context sharing the lease's starting bucket.
+	unrelated := timeline.Deadline(now.Add(ttl))
+
+	// Wait almost the full TTL, then refresh: the new target deadline
+	// (time.Now()+ttl at the time of the refresh) is far enough from the
+	// original bucket that the lease necessarily moves to a different one,
+	// vacating the bucket that unrelated still shares.
+	time.Sleep(ttl - (3 * timeline.Resolution))
+	if !lease.Refresh() {
+		t.Fatal("Refresh failed before the lease actually expired")
+	}
+
+	select {
+	case <-unrelated.Done():
+		t.Error("unrelated context sharing the lease's vacated bucket was canceled early")
+	case <-time.After(2 * timeline.Resolution):
+	}
+
+	// The vacated bucket is still alive and will expire unrelated on its own.
+	select {
+	case <-unrelated.Done():
+	case <-time.After(time.Second):
+		t.Fatal("unrelated context never expired on its own")
+	}
+
+	if err := unrelated.Err(); err != context.DeadlineExceeded {
+		t.Error("bad unrelated context error:", err)
+	}
+}
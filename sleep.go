@@ -2,6 +2,7 @@ package timers
 
 import (
 	"context"
+	"errors"
 	"time"
 )
 
@@ -18,3 +19,44 @@ func Sleep(ctx context.Context, duration time.Duration) (err error) {
 	timer.Stop()
 	return
 }
+
+// ErrInterrupted is returned by SleepUntil when wake is signaled before the
+// duration has passed or the context was canceled.
+var ErrInterrupted = errors.New("timers: sleep interrupted")
+
+// SleepUntil puts the calling goroutine to sleep until the given duration has
+// passed, the context is canceled, or wake is signaled, whichever comes
+// first. It returns the context's error if the context fired first, or
+// ErrInterrupted if wake fired first.
+func SleepUntil(ctx context.Context, duration time.Duration, wake <-chan struct{}) (err error) {
+	timer := time.NewTimer(duration)
+
+	select {
+	case <-timer.C:
+		return nil
+
+	case <-ctx.Done():
+		err = ctx.Err()
+
+	case <-wake:
+		err = ErrInterrupted
+	}
+
+	if !timer.Stop() {
+		<-timer.C
+	}
+	return
+}
+
+// SleepOn is the Timeline-backed variant of Sleep: instead of allocating a
+// private time.Timer, it puts the calling goroutine to sleep on t's shared
+// bucket for the given duration, so that callers with millions of
+// concurrent sleeps still pay only one runtime timer per resolution window.
+func SleepOn(t *Timeline, ctx context.Context, duration time.Duration) (err error) {
+	select {
+	case <-t.Timeout(duration).Done():
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+	return
+}
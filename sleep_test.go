@@ -11,6 +11,17 @@ func TestSleep(t *testing.T) {
 	t.Run("cancel", testSleepCancel)
 }
 
+func TestSleepUntil(t *testing.T) {
+	t.Run("timeout", testSleepUntilTimeout)
+	t.Run("cancel", testSleepUntilCancel)
+	t.Run("interrupted", testSleepUntilInterrupted)
+}
+
+func TestSleepOn(t *testing.T) {
+	t.Run("timeout", testSleepOnTimeout)
+	t.Run("cancel", testSleepOnCancel)
+}
+
 func testSleepTimeout(t *testing.T) {
 	t.Parallel()
 
@@ -50,3 +61,84 @@ func testSleepCancel(t *testing.T) {
 		t.Errorf("too much time has passed since sleep was called, expected less then %s but got %s", sleepDuration, elapsed)
 	}
 }
+
+func testSleepUntilTimeout(t *testing.T) {
+	t.Parallel()
+
+	const sleepDuration = 100 * time.Microsecond
+
+	err := SleepUntil(context.Background(), sleepDuration, nil)
+
+	if err != nil {
+		t.Errorf("unexpected error returned from SleepUntil, expected <nil> but got %q", err)
+	}
+}
+
+func testSleepUntilCancel(t *testing.T) {
+	t.Parallel()
+
+	const sleepDuration = 100 * time.Millisecond
+	const abortDuration = sleepDuration / 2
+
+	ctx, cancel := context.WithTimeout(context.Background(), abortDuration)
+	defer cancel()
+
+	err := SleepUntil(ctx, sleepDuration, nil)
+
+	if ctxErr := ctx.Err(); err != ctxErr {
+		t.Errorf("unexpected error returned from SleepUntil, expected %q but got %q", ctxErr, err)
+	}
+}
+
+func testSleepUntilInterrupted(t *testing.T) {
+	t.Parallel()
+
+	wake := make(chan struct{})
+	close(wake)
+
+	err := SleepUntil(context.Background(), 100*time.Millisecond, wake)
+
+	if err != ErrInterrupted {
+		t.Errorf("unexpected error returned from SleepUntil, expected %q but got %q", ErrInterrupted, err)
+	}
+}
+
+func testSleepOnTimeout(t *testing.T) {
+	t.Parallel()
+
+	timeline := Timeline{Resolution: 1 * time.Millisecond}
+	defer timeline.Cancel()
+
+	const sleepDuration = 10 * time.Millisecond
+
+	then := time.Now()
+	err := SleepOn(&timeline, context.Background(), sleepDuration)
+	now := time.Now()
+
+	if err != nil {
+		t.Errorf("unexpected error returned from SleepOn, expected <nil> but got %q", err)
+	}
+
+	if elapsed := now.Sub(then); elapsed < sleepDuration {
+		t.Errorf("not enough time has passed since sleep was called, expected more than %s but got %s", sleepDuration, elapsed)
+	}
+}
+
+func testSleepOnCancel(t *testing.T) {
+	t.Parallel()
+
+	timeline := Timeline{Resolution: 1 * time.Millisecond}
+	defer timeline.Cancel()
+
+	const sleepDuration = 100 * time.Millisecond
+	const abortDuration = sleepDuration / 2
+
+	ctx, cancel := context.WithTimeout(context.Background(), abortDuration)
+	defer cancel()
+
+	err := SleepOn(&timeline, ctx, sleepDuration)
+
+	if ctxErr := ctx.Err(); err != ctxErr {
+		t.Errorf("unexpected error returned from SleepOn, expected %q but got %q", ctxErr, err)
+	}
+}
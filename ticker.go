@@ -0,0 +1,81 @@
+package timers
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// Ticker delivers periodic ticks on its channel C, built on top of
+// Timeline.AfterFunc so that every Ticker (and every other Timeline caller)
+// whose next tick rounds up to the same resolution window shares the same
+// underlying timer.
+//
+// Like time.Ticker, a slow consumer does not cause ticks to back up: if a
+// tick is not received before the next one is due, the new tick is dropped.
+type Ticker struct {
+	C <-chan time.Time
+
+	c        chan time.Time
+	timeline *Timeline
+	period   int64  // time.Duration, accessed atomically
+	gen      uint64 // accessed atomically; bumped by Stop/Reset to invalidate in-flight reschedules
+}
+
+// NewTicker returns a new Ticker that ticks every period, plus up to the
+// timeline's resolution. If period is less than the timeline's resolution, it
+// is rounded up to the resolution.
+func (t *Timeline) NewTicker(period time.Duration) *Ticker {
+	c := make(chan time.Time, 1)
+	tk := &Ticker{
+		C:        c,
+		c:        c,
+		timeline: t,
+	}
+	atomic.StoreInt64(&tk.period, int64(tk.normalize(period)))
+	tk.schedule(time.Now(), atomic.LoadUint64(&tk.gen))
+	return tk
+}
+
+// Stop turns off the ticker. It does not close the channel, to prevent a
+// read from the channel from succeeding incorrectly, matching time.Ticker.
+func (tk *Ticker) Stop() {
+	atomic.AddUint64(&tk.gen, 1)
+}
+
+// Reset stops the ticker and changes its period; the next tick arrives after
+// the new period has elapsed, plus up to the timeline's resolution. It is
+// safe to call Reset concurrently with receives from tk.C.
+func (tk *Ticker) Reset(period time.Duration) {
+	atomic.StoreInt64(&tk.period, int64(tk.normalize(period)))
+	gen := atomic.AddUint64(&tk.gen, 1)
+	tk.schedule(time.Now(), gen)
+}
+
+func (tk *Ticker) normalize(period time.Duration) time.Duration {
+	if r := tk.timeline.resolution(); period < r {
+		return r
+	}
+	return period
+}
+
+// schedule attaches a callback to the bucket for the next tick. gen pins the
+// reschedule chain to the generation it was started from, so a Stop or Reset
+// that happens while the callback is in flight silently ends the chain
+// instead of racing a new one.
+func (tk *Ticker) schedule(from time.Time, gen uint64) {
+	period := time.Duration(atomic.LoadInt64(&tk.period))
+	next := from.Add(period)
+
+	tk.timeline.AfterFuncAt(next, func() {
+		if atomic.LoadUint64(&tk.gen) != gen {
+			return
+		}
+
+		select {
+		case tk.c <- next:
+		default: // slow consumer: drop the tick, like time.Ticker does
+		}
+
+		tk.schedule(next, gen)
+	})
+}
@@ -0,0 +1,46 @@
+package timers
+
+import (
+	"testing"
+	"time"
+)
+
+func TestTicker(t *testing.T) {
+	t.Run("ticks repeatedly", testTickerTicks)
+	t.Run("stop prevents further ticks", testTickerStop)
+}
+
+func testTickerTicks(t *testing.T) {
+	t.Parallel()
+
+	timeline := Timeline{Resolution: 1 * time.Millisecond}
+	defer timeline.Cancel()
+
+	ticker := timeline.NewTicker(5 * time.Millisecond)
+	defer ticker.Stop()
+
+	for i := 0; i != 3; i++ {
+		select {
+		case <-ticker.C:
+		case <-time.After(time.Second):
+			t.Fatal("ticker did not tick in time")
+		}
+	}
+}
+
+func testTickerStop(t *testing.T) {
+	t.Parallel()
+
+	timeline := Timeline{Resolution: 1 * time.Millisecond}
+	defer timeline.Cancel()
+
+	ticker := timeline.NewTicker(5 * time.Millisecond)
+	<-ticker.C
+	ticker.Stop()
+
+	select {
+	case <-ticker.C:
+		t.Error("ticker ticked after being stopped")
+	case <-time.After(30 * time.Millisecond):
+	}
+}
@@ -3,6 +3,7 @@ package timers
 import (
 	"context"
 	"math/rand"
+	"runtime"
 	"sync"
 	"sync/atomic"
 	"time"
@@ -15,6 +16,10 @@ import (
 // to create thousands, or even millions of them since the runtime only needs to
 // maintain a single timer per resolution window.
 //
+// Internally, a Timeline spreads its deadlines across a number of shards, each
+// guarded by its own mutex, so that goroutines targeting different resolution
+// windows don't contend on a single lock.
+//
 // Timelines are safe to use concurrently from multiple goroutines, however they
 // should not be copied after being first used.
 //
@@ -30,6 +35,20 @@ type Timeline struct {
 	// the timeline. If nil, the default background context is used instead.
 	Background context.Context
 
+	// Shards sets the number of internal shards that the timeline splits its
+	// deadlines across, which reduces contention on the lock guarding each
+	// shard's map. It is rounded up to the next power of two. If zero, the
+	// value of runtime.GOMAXPROCS(0) is used instead.
+	Shards int
+
+	once   sync.Once
+	shards []timelineShard
+}
+
+// timelineShard holds a subset of the deadlines managed by a Timeline. Every
+// bucket key is always routed to the same shard, so a bucket is still shared
+// by every caller that targets it, regardless of which shard it lives on.
+type timelineShard struct {
 	mutex     sync.RWMutex
 	deadlines map[int64]deadline
 
@@ -61,14 +80,19 @@ var (
 // Cancel cancels all contexts and releases all internal resources managed by
 // the timeline.
 func (t *Timeline) Cancel() {
-	t.mutex.Lock()
+	t.init()
 
-	for _, d := range t.deadlines {
-		d.cancel()
-	}
+	for i := range t.shards {
+		s := &t.shards[i]
+		s.mutex.Lock()
 
-	t.deadlines = nil
-	t.mutex.Unlock()
+		for _, d := range s.deadlines {
+			d.cancel()
+		}
+
+		s.deadlines = nil
+		s.mutex.Unlock()
+	}
 }
 
 // Timeout returns a context which expires after the given amount of time has
@@ -87,86 +111,156 @@ func (t *Timeline) Deadline(deadline time.Time) context.Context {
 // Context returns a context which expires when the given deadline is reached,
 // using `now` as the current time.
 func (t *Timeline) Context(at time.Time, now time.Time) context.Context {
-	r := int64(t.resolution())
-	k := at.UnixNano()
+	d := t.bucket(at, now)
+	return d.context
+}
 
-	// Round up to the nearest resoltion, unless the time already is a multiple
-	// of the timeline resolution.
-	if (k % r) != 0 {
-		k = ((k / r) + 1) * r
+// ContextWithParent is the parent-aware variant of Context: the returned
+// context's Deadline, Done and Err still come from the shared bucket for
+// `at`, but its Value lookups are forwarded to parent instead of the
+// timeline's Background. This lets callers that need request-scoped values
+// (a task ID, a tracing span, ...) still share a bucket with every other
+// caller expiring in the same resolution window.
+func (t *Timeline) ContextWithParent(parent context.Context, at time.Time, now time.Time) context.Context {
+	d := t.bucket(at, now)
+	if parent == nil {
+		return d.context
+	}
+	return valueParentContext{Context: d.context, parent: parent}
+}
+
+// valueParentContext forwards Deadline, Done and Err to an embedded bucket
+// context, but Value to a separate parent. It exists so that contexts
+// sharing a Timeline bucket can still carry caller-specific values.
+type valueParentContext struct {
+	context.Context
+	parent context.Context
+}
+
+func (c valueParentContext) Value(key interface{}) interface{} {
+	return c.parent.Value(key)
+}
+
+// TimeoutOrDeadline returns a context that expires at whichever comes first
+// of now+timeout (if timeout is greater than zero) and deadline (if it is
+// non-zero), falling back to now+fallback if neither is set. This is the
+// "whichever comes first" logic that task processors handling messages with
+// both a per-task timeout and an absolute deadline otherwise end up
+// duplicating. The returned context inherits values from parent and, like
+// every other Timeline context, shares a bucket with other calls expiring in
+// the same resolution window.
+func (t *Timeline) TimeoutOrDeadline(parent context.Context, timeout time.Duration, deadline time.Time, fallback time.Duration) context.Context {
+	now := time.Now()
+	at := now.Add(fallback)
+
+	switch {
+	case timeout > 0 && !deadline.IsZero():
+		if byTimeout := now.Add(timeout); byTimeout.Before(deadline) {
+			at = byTimeout
+		} else {
+			at = deadline
+		}
+	case timeout > 0:
+		at = now.Add(timeout)
+	case !deadline.IsZero():
+		at = deadline
 	}
 
-	t.mutex.RLock()
-	d, ok := t.deadlines[k]
-	t.mutex.RUnlock()
+	return t.ContextWithParent(parent, at, now)
+}
+
+// bucket returns the deadline bucket that `at` rounds up to, creating it if it
+// does not exist yet.
+func (t *Timeline) bucket(at time.Time, now time.Time) deadline {
+	t.init()
+
+	r := int64(t.resolution())
+	k := bucketKey(at, r)
+	s := t.shardFor(k)
+
+	s.mutex.RLock()
+	d, ok := s.deadlines[k]
+	s.mutex.RUnlock()
 
 	if ok { // fast path
-		return d.context
+		return d
 	}
 
 	background := t.background()
 	expiration := time.Unix(0, k)
 
-	t.mutex.Lock()
-	d, ok = t.deadlines[k]
+	s.mutex.Lock()
+	d, ok = s.deadlines[k]
 	if !ok {
-		if t.deadlines == nil {
-			t.deadlines = make(map[int64]deadline)
+		if s.deadlines == nil {
+			s.deadlines = make(map[int64]deadline)
 		}
 		d = makeDeadline(background, jitterTime(expiration, time.Duration(r)))
-		t.deadlines[k] = d
+		s.deadlines[k] = d
 	}
-	t.mutex.Unlock()
+	s.mutex.Unlock()
 
-	if cleanupTime := t.loadCleanupTime(); cleanupTime.IsZero() || cleanupTime.Before(now) {
-		if t.tryLockCleanup() {
-			t.storeCleanupTime(t.nextCleanupTime(cleanupTime))
-			t.cleanup(now)
-			t.unlockCleanup()
-		}
+	s.maybeCleanup(t, now)
+	return d
+}
+
+// bucketKey rounds `at` up to the nearest multiple of the resolution `r`,
+// unless it already is one.
+func bucketKey(at time.Time, r int64) int64 {
+	k := at.UnixNano()
+	if (k % r) != 0 {
+		k = ((k / r) + 1) * r
 	}
+	return k
+}
 
-	return d.context
+func (s *timelineShard) maybeCleanup(t *Timeline, now time.Time) {
+	if cleanupTime := s.loadCleanupTime(); cleanupTime.IsZero() || cleanupTime.Before(now) {
+		if s.tryLockCleanup() {
+			s.storeCleanupTime(t.nextCleanupTime(cleanupTime))
+			s.cleanup(t.resolution(), now)
+			s.unlockCleanup()
+		}
+	}
 }
 
 func (t *Timeline) nextCleanupTime(lastCleanupTime time.Time) time.Time {
 	return lastCleanupTime.Add(100 * t.resolution())
 }
 
-func (t *Timeline) loadCleanupTime() time.Time {
-	return time.Unix(0, atomic.LoadInt64(&t.cleanupTime))
+func (s *timelineShard) loadCleanupTime() time.Time {
+	return time.Unix(0, atomic.LoadInt64(&s.cleanupTime))
 }
 
-func (t *Timeline) storeCleanupTime(cleanupTime time.Time) {
-	atomic.StoreInt64(&t.cleanupTime, cleanupTime.UnixNano())
+func (s *timelineShard) storeCleanupTime(cleanupTime time.Time) {
+	atomic.StoreInt64(&s.cleanupTime, cleanupTime.UnixNano())
 }
 
-func (t *Timeline) tryLockCleanup() bool {
-	return atomic.CompareAndSwapInt64(&t.cleanupLock, 0, 1)
+func (s *timelineShard) tryLockCleanup() bool {
+	return atomic.CompareAndSwapInt64(&s.cleanupLock, 0, 1)
 }
 
-func (t *Timeline) unlockCleanup() {
-	atomic.StoreInt64(&t.cleanupLock, 0)
+func (s *timelineShard) unlockCleanup() {
+	atomic.StoreInt64(&s.cleanupLock, 0)
 }
 
-func (t *Timeline) cleanup(now time.Time) {
-	r := t.resolution()
-	t.mutex.RLock()
+func (s *timelineShard) cleanup(r time.Duration, now time.Time) {
+	s.mutex.RLock()
 
-	for k, d := range t.deadlines {
-		t.mutex.RUnlock()
+	for k, d := range s.deadlines {
+		s.mutex.RUnlock()
 
 		if deadline, _ := d.context.Deadline(); now.After(deadline.Add(r)) {
 			d.cancel()
-			t.mutex.Lock()
-			delete(t.deadlines, k)
-			t.mutex.Unlock()
+			s.mutex.Lock()
+			delete(s.deadlines, k)
+			s.mutex.Unlock()
 		}
 
-		t.mutex.RLock()
+		s.mutex.RLock()
 	}
 
-	t.mutex.RUnlock()
+	s.mutex.RUnlock()
 }
 
 func (t *Timeline) resolution() time.Duration {
@@ -183,9 +277,54 @@ func (t *Timeline) background() context.Context {
 	return context.Background()
 }
 
+// init lazily allocates the timeline's shards. It is safe to call
+// concurrently and is idempotent.
+func (t *Timeline) init() {
+	t.once.Do(func() {
+		n := t.Shards
+		if n <= 0 {
+			n = runtime.GOMAXPROCS(0)
+		}
+		t.shards = make([]timelineShard, nextPowerOfTwo(n))
+	})
+}
+
+// shardFor returns the shard that owns bucket key k. Every caller computing
+// the same bucket key is routed to the same shard, so the deadline stored
+// there is still shared across all of them.
+func (t *Timeline) shardFor(k int64) *timelineShard {
+	i := shardIndex(k, len(t.shards))
+	return &t.shards[i]
+}
+
+// shardIndex mixes the bucket key's bits (MurmurHash3's fmix64 finalizer)
+// before masking so that consecutive resolution windows, which only differ
+// in their low bits, still spread evenly across shards.
+func shardIndex(k int64, n int) int {
+	h := uint64(k)
+	h ^= h >> 33
+	h *= 0xff51afd7ed558ccd
+	h ^= h >> 33
+	h *= 0xc4ceb9fe1a85ec53
+	h ^= h >> 33
+	return int(h & uint64(n-1))
+}
+
+func nextPowerOfTwo(n int) int {
+	if n <= 1 {
+		return 1
+	}
+	p := 1
+	for p < n {
+		p <<= 1
+	}
+	return p
+}
+
 type deadline struct {
 	context context.Context
 	cancel  context.CancelFunc
+	pending *pendingCallbacks
 }
 
 func makeDeadline(parent context.Context, expiration time.Time) deadline {
@@ -193,6 +332,99 @@ func makeDeadline(parent context.Context, expiration time.Time) deadline {
 	return deadline{
 		context: context,
 		cancel:  cancel,
+		pending: &pendingCallbacks{},
+	}
+}
+
+// CancelFunc cancels a scheduled operation, such as one created by
+// Timeline.AfterFunc. Calling it more than once has no effect.
+type CancelFunc func()
+
+// AfterFunc schedules fn to run after d has passed, plus up to the
+// timeline's resolution. It returns a CancelFunc which removes fn from the
+// schedule; the cancellation has no effect if the bucket has already fired.
+// If the timeline is canceled, or its Background context is done, before
+// then, fn runs at that point instead.
+//
+// Unlike time.AfterFunc, AfterFunc does not spawn a goroutine for every call:
+// a single goroutine is started lazily for the underlying resolution window,
+// shared by every callback attached to it, which makes this the callback
+// equivalent of the context sharing that Timeline already does for
+// deadlines.
+func (t *Timeline) AfterFunc(d time.Duration, fn func()) CancelFunc {
+	return t.AfterFuncAt(time.Now().Add(d), fn)
+}
+
+// AfterFuncAt is the deadline based variant of AfterFunc.
+func (t *Timeline) AfterFuncAt(at time.Time, fn func()) CancelFunc {
+	return t.bucket(at, time.Now()).onDone(fn)
+}
+
+// onDone attaches fn to the bucket's pending callbacks, starting the
+// bucket's single watcher goroutine if this is the first callback attached
+// to it. It returns a CancelFunc that detaches fn again.
+func (d deadline) onDone(fn func()) CancelFunc {
+	id, immediate, first := d.pending.add(fn)
+	if immediate {
+		go fn()
+		return func() {}
+	}
+
+	if first {
+		go func() {
+			<-d.context.Done()
+			d.pending.fire()
+		}()
+	}
+
+	return func() { d.pending.remove(id) }
+}
+
+// pendingCallbacks holds the set of callbacks attached to a deadline bucket
+// via Timeline.AfterFunc, and the bookkeeping needed to fire them exactly
+// once, from a single goroutine, when the bucket's context is done.
+type pendingCallbacks struct {
+	mutex   sync.Mutex
+	started bool
+	fired   bool
+	nextID  int
+	funcs   map[int]func()
+}
+
+func (p *pendingCallbacks) add(fn func()) (id int, immediate bool, first bool) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	if p.fired {
+		return 0, true, false
+	}
+
+	id = p.nextID
+	p.nextID++
+	if p.funcs == nil {
+		p.funcs = make(map[int]func())
+	}
+	p.funcs[id] = fn
+
+	first, p.started = !p.started, true
+	return id, false, first
+}
+
+func (p *pendingCallbacks) remove(id int) {
+	p.mutex.Lock()
+	delete(p.funcs, id)
+	p.mutex.Unlock()
+}
+
+func (p *pendingCallbacks) fire() {
+	p.mutex.Lock()
+	p.fired = true
+	funcs := p.funcs
+	p.funcs = nil
+	p.mutex.Unlock()
+
+	for _, fn := range funcs {
+		fn()
 	}
 }
 
@@ -2,6 +2,7 @@ package timers
 
 import (
 	"context"
+	"fmt"
 	"sync"
 	"testing"
 	"time"
@@ -28,6 +29,22 @@ func TestTimeline(t *testing.T) {
 			scenario: "canceling the background context of a timeline also cancels contexts that it created",
 			function: testTimelineBackground,
 		},
+		{
+			scenario: "AfterFunc runs the callback once the deadline is reached",
+			function: testTimelineAfterFunc,
+		},
+		{
+			scenario: "canceling the func returned by AfterFunc prevents the callback from running",
+			function: testTimelineAfterFuncCancel,
+		},
+		{
+			scenario: "ContextWithParent forwards values from the given parent",
+			function: testTimelineContextWithParent,
+		},
+		{
+			scenario: "TimeoutOrDeadline picks whichever of timeout and deadline comes first",
+			function: testTimelineTimeoutOrDeadline,
+		},
 	}
 
 	for _, test := range tests {
@@ -118,6 +135,80 @@ func testTimelineBackground(t *testing.T) {
 	}
 }
 
+func testTimelineAfterFunc(t *testing.T) {
+	timeline := Timeline{Resolution: 1 * time.Millisecond}
+	defer timeline.Cancel()
+
+	done := make(chan struct{})
+	timeline.AfterFunc(10*time.Millisecond, func() { close(done) })
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Error("AfterFunc callback was not invoked in time")
+	}
+}
+
+func testTimelineAfterFuncCancel(t *testing.T) {
+	timeline := Timeline{Resolution: 1 * time.Millisecond}
+	defer timeline.Cancel()
+
+	called := make(chan struct{})
+	cancel := timeline.AfterFunc(10*time.Millisecond, func() { close(called) })
+	cancel()
+
+	select {
+	case <-called:
+		t.Error("canceled AfterFunc callback was invoked")
+	case <-time.After(20 * time.Millisecond):
+	}
+}
+
+type contextKey string
+
+func testTimelineContextWithParent(t *testing.T) {
+	timeline := Timeline{Resolution: 1 * time.Millisecond}
+	defer timeline.Cancel()
+
+	parent := context.WithValue(context.Background(), contextKey("task-id"), "42")
+	ctx := timeline.ContextWithParent(parent, time.Now().Add(10*time.Millisecond), time.Now())
+
+	if v, _ := ctx.Value(contextKey("task-id")).(string); v != "42" {
+		t.Errorf("value was not forwarded from the parent, got %q", v)
+	}
+
+	<-ctx.Done()
+
+	if err := ctx.Err(); err != context.DeadlineExceeded {
+		t.Error("bad context error:", err)
+	}
+}
+
+func testTimelineTimeoutOrDeadline(t *testing.T) {
+	timeline := Timeline{Resolution: 1 * time.Millisecond}
+	defer timeline.Cancel()
+
+	now := time.Now()
+
+	// timeout comes first
+	ctx := timeline.TimeoutOrDeadline(context.Background(), 10*time.Millisecond, now.Add(time.Hour), time.Hour)
+	if d, _ := ctx.Deadline(); d.After(now.Add(20 * time.Millisecond)) {
+		t.Error("TimeoutOrDeadline did not pick the earlier timeout")
+	}
+
+	// deadline comes first
+	ctx = timeline.TimeoutOrDeadline(context.Background(), time.Hour, now.Add(10*time.Millisecond), time.Hour)
+	if d, _ := ctx.Deadline(); d.After(now.Add(20 * time.Millisecond)) {
+		t.Error("TimeoutOrDeadline did not pick the earlier deadline")
+	}
+
+	// neither set, falls back
+	ctx = timeline.TimeoutOrDeadline(context.Background(), 0, time.Time{}, 10*time.Millisecond)
+	if d, _ := ctx.Deadline(); d.After(now.Add(20 * time.Millisecond)) {
+		t.Error("TimeoutOrDeadline did not fall back to the given duration")
+	}
+}
+
 func BenchmarkTimeline(b *testing.B) {
 	timeouts := []time.Duration{
 		100 * time.Millisecond,
@@ -136,3 +227,23 @@ func BenchmarkTimeline(b *testing.B) {
 		}
 	})
 }
+
+// BenchmarkTimelineCommonParentCancel measures how contention on a shared
+// deadline bucket scales with the number of shards. Run with -cpu to compare
+// against GOMAXPROCS, e.g. `go test -bench=CommonParentCancel -cpu=1,2,4,8`.
+func BenchmarkTimelineCommonParentCancel(b *testing.B) {
+	for _, shards := range []int{1, 2, 4, 8, 16} {
+		b.Run(fmt.Sprintf("shards-%d", shards), func(b *testing.B) {
+			timeline := Timeline{Shards: shards}
+			defer timeline.Cancel()
+
+			deadline := time.Now().Add(time.Hour)
+
+			b.RunParallel(func(pb *testing.PB) {
+				for pb.Next() {
+					timeline.Deadline(deadline)
+				}
+			})
+		})
+	}
+}